@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// CloudConfig describes a single OpenStack cloud/tenant that can be probed,
+// along with the per-target overrides used to run that probe.
+type CloudConfig struct {
+	Name              string `yaml:"name"`
+	AuthURL           string `yaml:"auth_url"`
+	Username          string `yaml:"username"`
+	Password          string `yaml:"password"`
+	ProjectName       string `yaml:"project_name"`
+	UserDomainName    string `yaml:"user_domain_name"`
+	ProjectDomainName string `yaml:"project_domain_name"`
+
+	// Per-target overrides. When empty, the exporter's command line
+	// defaults are used instead.
+	FlavorName      string `yaml:"flavor,omitempty"`
+	ImageName       string `yaml:"image,omitempty"`
+	InternalNetwork string `yaml:"internal_network,omitempty"`
+	ExternalNetwork string `yaml:"external_network,omitempty"`
+}
+
+// ExporterConfig is the top level structure of the -config-file YAML
+// document: a list of clouds that can be selected via /probe?target=<name>.
+type ExporterConfig struct {
+	Clouds []CloudConfig `yaml:"clouds"`
+}
+
+// loadConfig reads and parses the multi-target configuration file.
+func loadConfig(path string) (*ExporterConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %s", err)
+	}
+
+	var cfg ExporterConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse config file: %s", err)
+	}
+
+	return &cfg, nil
+}
+
+// cloudByName looks up a cloud entry by its configured name, as referenced by
+// the target query parameter of the /probe endpoint.
+func (c *ExporterConfig) cloudByName(name string) (*CloudConfig, error) {
+	for i := range c.Clouds {
+		if c.Clouds[i].Name == name {
+			return &c.Clouds[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no cloud named %q in config file", name)
+}
+
+// envCloudConfig builds a CloudConfig from the OS_* environment variables,
+// used by the legacy single-tenant /metrics endpoint.
+func envCloudConfig() CloudConfig {
+	return CloudConfig{
+		Name:              "env",
+		AuthURL:           os.Getenv("OS_AUTH_URL"),
+		Username:          os.Getenv("OS_USERNAME"),
+		Password:          os.Getenv("OS_PASSWORD"),
+		ProjectName:       os.Getenv("OS_PROJECT_NAME"),
+		UserDomainName:    os.Getenv("OS_USER_DOMAIN_NAME"),
+		ProjectDomainName: os.Getenv("OS_PROJECT_DOMAIN_NAME"),
+	}
+}
+
+// cloudConfigContextKey is an unexported type so the CloudConfig stashed on a
+// context can't collide with keys set by other packages.
+type cloudConfigContextKey struct{}
+
+// withCloudConfig attaches the cloud being probed to ctx, so that getProvider
+// can authenticate against the right target without threading a CloudConfig
+// argument through every caller.
+func withCloudConfig(ctx context.Context, cc CloudConfig) context.Context {
+	return context.WithValue(ctx, cloudConfigContextKey{}, cc)
+}
+
+// cloudConfigFromContext retrieves the CloudConfig attached by withCloudConfig,
+// falling back to the OS_* environment variables if none was attached.
+func cloudConfigFromContext(ctx context.Context) CloudConfig {
+	if cc, ok := ctx.Value(cloudConfigContextKey{}).(CloudConfig); ok {
+		return cc
+	}
+
+	return envCloudConfig()
+}
+
+// The flavorFor/imageFor/*NetworkFor helpers resolve the effective value for
+// a probe: the cloud's own per-target override if it set one, otherwise the
+// exporter's command line default. They read cc directly rather than
+// mutating the flavorName/imageName/*Network package vars, so that two
+// concurrent probes for different clouds never race on shared state.
+func flavorFor(cc CloudConfig) string {
+	if cc.FlavorName != "" {
+		return cc.FlavorName
+	}
+	return flavorName
+}
+
+func imageFor(cc CloudConfig) string {
+	if cc.ImageName != "" {
+		return cc.ImageName
+	}
+	return imageName
+}
+
+func internalNetworkFor(cc CloudConfig) string {
+	if cc.InternalNetwork != "" {
+		return cc.InternalNetwork
+	}
+	return internalNetwork
+}
+
+func externalNetworkFor(cc CloudConfig) string {
+	if cc.ExternalNetwork != "" {
+		return cc.ExternalNetwork
+	}
+	return externalNetwork
+}
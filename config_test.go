@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clouds.yaml")
+
+	data := []byte(`
+clouds:
+  - name: alpha
+    auth_url: https://alpha.example.com/v3
+    username: alpha-user
+    flavor: m1.large
+  - name: beta
+    auth_url: https://beta.example.com/v3
+    username: beta-user
+`)
+	if err := ioutil.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("cannot write fixture: %v", err)
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	if len(cfg.Clouds) != 2 {
+		t.Fatalf("len(cfg.Clouds) = %d, want 2", len(cfg.Clouds))
+	}
+
+	if cfg.Clouds[0].Name != "alpha" || cfg.Clouds[0].FlavorName != "m1.large" {
+		t.Errorf("cfg.Clouds[0] = %+v, want name=alpha flavor=m1.large", cfg.Clouds[0])
+	}
+}
+
+func TestCloudByName(t *testing.T) {
+	cfg := &ExporterConfig{
+		Clouds: []CloudConfig{
+			{Name: "alpha", AuthURL: "https://alpha.example.com/v3"},
+			{Name: "beta", AuthURL: "https://beta.example.com/v3"},
+		},
+	}
+
+	cc, err := cfg.cloudByName("beta")
+	if err != nil {
+		t.Fatalf("cloudByName() error = %v", err)
+	}
+	if cc.AuthURL != "https://beta.example.com/v3" {
+		t.Errorf("cc.AuthURL = %q, want %q", cc.AuthURL, "https://beta.example.com/v3")
+	}
+
+	if _, err := cfg.cloudByName("missing"); err == nil {
+		t.Error("cloudByName(\"missing\") error = nil, want an error")
+	}
+}
+
+func TestOverrideHelpersFallBackToFlagDefaults(t *testing.T) {
+	flavorName, imageName = "t2.small", "ubuntu-16.04-x86_64"
+	internalNetwork, externalNetwork = "private", "internet"
+
+	withOverride := CloudConfig{FlavorName: "m1.large"}
+	withoutOverride := CloudConfig{}
+
+	if got := flavorFor(withOverride); got != "m1.large" {
+		t.Errorf("flavorFor(withOverride) = %q, want %q", got, "m1.large")
+	}
+	if got := flavorFor(withoutOverride); got != flavorName {
+		t.Errorf("flavorFor(withoutOverride) = %q, want %q", got, flavorName)
+	}
+	if got := imageFor(withoutOverride); got != imageName {
+		t.Errorf("imageFor(withoutOverride) = %q, want %q", got, imageName)
+	}
+	if got := internalNetworkFor(withoutOverride); got != internalNetwork {
+		t.Errorf("internalNetworkFor(withoutOverride) = %q, want %q", got, internalNetwork)
+	}
+	if got := externalNetworkFor(withoutOverride); got != externalNetwork {
+		t.Errorf("externalNetworkFor(withoutOverride) = %q, want %q", got, externalNetwork)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/objectstorage/v1/containers"
+)
+
+// runGarbageCollector periodically sweeps away any probe resources left
+// behind by a crashed or timed-out run, identified by the resourceTag
+// prefix createName() gives them.
+func runGarbageCollector() {
+	for range time.Tick(garbageCollectorSleep) {
+		collectGarbage()
+	}
+}
+
+// collectGarbage sweeps every cloud the exporter knows how to probe: the
+// legacy OS_* environment, plus every cloud listed in -config-file, so
+// clouds only ever reached via /probe?target= still get their leftover
+// resources reaped.
+func collectGarbage() {
+	clouds := []CloudConfig{envCloudConfig()}
+
+	if configFile != "" {
+		cfg, err := loadConfig(configFile)
+		if err != nil {
+			log.Printf("garbage collector: %s", err)
+		} else {
+			clouds = append(clouds, cfg.Clouds...)
+		}
+	}
+
+	for _, cc := range clouds {
+		collectGarbageForCloud(cc)
+	}
+}
+
+func collectGarbageForCloud(cc CloudConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	provider, err := getProvider(withCloudConfig(ctx, cc))
+	if err != nil {
+		log.Printf("garbage collector: %s: %s", cc.Name, err)
+		return
+	}
+
+	if !disableInstance {
+		collectStaleServers(provider)
+	}
+
+	if !disableObjectStore {
+		collectStaleContainers(provider)
+	}
+}
+
+func collectStaleServers(provider *gophercloud.ProviderClient) {
+	client, err := openstack.NewComputeV2(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		log.Printf("garbage collector: cannot create compute client: %s", err)
+		return
+	}
+
+	page, err := servers.List(client, servers.ListOpts{Name: resourceTag}).AllPages()
+	if err != nil {
+		log.Printf("garbage collector: cannot list servers: %s", err)
+		return
+	}
+
+	all, err := servers.ExtractServers(page)
+	if err != nil {
+		log.Printf("garbage collector: cannot extract servers: %s", err)
+		return
+	}
+
+	for _, server := range all {
+		if !strings.HasPrefix(server.Name, resourceTag) {
+			continue
+		}
+
+		if err := servers.Delete(client, server.ID).ExtractErr(); err != nil {
+			log.Printf("garbage collector: cannot delete stale server %s: %s", server.Name, err)
+		}
+	}
+}
+
+func collectStaleContainers(provider *gophercloud.ProviderClient) {
+	client, err := openstack.NewObjectStorageV1(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		log.Printf("garbage collector: cannot create object store client: %s", err)
+		return
+	}
+
+	page, err := containers.List(client, containers.ListOpts{Prefix: resourceTag}).AllPages()
+	if err != nil {
+		log.Printf("garbage collector: cannot list containers: %s", err)
+		return
+	}
+
+	names, err := containers.ExtractNames(page)
+	if err != nil {
+		log.Printf("garbage collector: cannot extract containers: %s", err)
+		return
+	}
+
+	if len(names) == 0 {
+		// Nothing stale to reap, so leave the account's Temp-Url-Key alone:
+		// clearing it here would invalidate any TempURL a concurrent probe
+		// just signed, every tick.
+		return
+	}
+
+	for _, name := range names {
+		if _, err := containers.Delete(client, name).Extract(); err != nil {
+			log.Printf("garbage collector: cannot delete stale container %s: %s", name, err)
+		}
+	}
+
+	if err := cleanupTempURLKey(client); err != nil {
+		log.Printf("garbage collector: cannot clean up temp url key: %s", err)
+	}
+}
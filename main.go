@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	kitlog "github.com/go-kit/log"
 	"github.com/prometheus/common/version"
 
 	"github.com/gophercloud/gophercloud"
@@ -20,6 +21,7 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
 )
 
 const (
@@ -30,24 +32,33 @@ const (
 )
 
 var (
-	requestTimeout     time.Duration
-	flavorName         string
-	imageName          string
-	internalNetwork    string
-	externalNetwork    string
-	userName           string
-	disableObjectStore bool
-	disableInstance    bool
+	requestTimeout      time.Duration
+	flavorName          string
+	imageName           string
+	internalNetwork     string
+	externalNetwork     string
+	userName            string
+	disableObjectStore  bool
+	disableInstance     bool
+	configFile          string
+	pushGatewayURL      string
+	runOnce             bool
+	maxConcurrentProbes int
+	exportTimestamp     bool
+	webListenAddress    string
+	webConfigFile       string
 )
 
-func metricsHandler(w http.ResponseWriter, r *http.Request) {
-	registry := prometheus.NewRegistry()
+// runProbe authenticates against the cloud described by cc and runs the
+// instance and object store checks against it, registering their metrics on
+// registry. It is shared by the single-tenant /metrics endpoint and the
+// multi-target /probe endpoint.
+func runProbe(ctx context.Context, registry *prometheus.Registry, cc CloudConfig) {
+	ctx = withCloudConfig(ctx, cc)
 
 	registry.MustRegister(version.NewCollector("openstack_client_exporter"))
 	registry.MustRegister(prometheus.NewGoCollector())
-
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
-	defer cancel()
+	registry.MustRegister(probeQueueDepth, probeInflight)
 
 	wg := sync.WaitGroup{}
 
@@ -71,25 +82,82 @@ func metricsHandler(w http.ResponseWriter, r *http.Request) {
 			wg.Done()
 			log.Printf("objectStoreMain finished in %v", time.Since(start))
 		}()
+	}
+
+	wg.Wait()
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	acquireProbeSlot()
+	defer releaseProbeSlot()
+
+	registry := prometheus.NewRegistry()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	runProbe(ctx, registry, envCloudConfig())
+
+	promhttp.HandlerFor(gathererFor(registry, start), promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probeHandler implements the Prometheus multi-target exporter pattern: the
+// caller selects which cloud to probe via the target query parameter, and
+// the matching entry from -config-file supplies its credentials and
+// per-target overrides.
+func probeHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if configFile == "" {
+		http.Error(w, "-config-file is not configured", http.StatusInternalServerError)
+		return
+	}
+
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		wg.Wait()
+	cc, err := cfg.cloudByName(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
 
-	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	acquireProbeSlot()
+	defer releaseProbeSlot()
+
+	registry := prometheus.NewRegistry()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	runProbe(ctx, registry, *cc)
+
+	promhttp.HandlerFor(gathererFor(registry, start), promhttp.HandlerOpts{}).ServeHTTP(w, r)
 }
 
 func getProvider(ctx context.Context) (*gophercloud.ProviderClient, error) {
+	cc := cloudConfigFromContext(ctx)
+
 	opts := tokens.AuthOptions{
-		Username:   os.Getenv("OS_USERNAME"),
-		DomainName: os.Getenv("OS_USER_DOMAIN_NAME"),
-		Password:   os.Getenv("OS_PASSWORD"),
+		Username:   cc.Username,
+		DomainName: cc.UserDomainName,
+		Password:   cc.Password,
 		Scope: tokens.Scope{
-			ProjectName: os.Getenv("OS_PROJECT_NAME"),
-			DomainName:  os.Getenv("OS_PROJECT_DOMAIN_NAME"),
+			ProjectName: cc.ProjectName,
+			DomainName:  cc.ProjectDomainName,
 		},
 	}
 
-	provider, err := openstack.NewClient(os.Getenv("OS_AUTH_URL"))
+	provider, err := openstack.NewClient(cc.AuthURL)
 
 	if err != nil {
 		return nil, fmt.Errorf("cannot create OpenStack client: %s", err)
@@ -107,17 +175,6 @@ func getProvider(ctx context.Context) (*gophercloud.ProviderClient, error) {
 	return provider, err
 }
 
-func step(ctx context.Context, timing prometheus.GaugeVec, name string) error {
-	timing.With(prometheus.Labels{"step": name}).SetToCurrentTime()
-
-	select {
-	case <-ctx.Done():
-		return fmt.Errorf("timeout after %s", name)
-	default:
-		return nil
-	}
-}
-
 func createName() string {
 	// A timestamp is included in the resource name because it is impossible
 	// to get reliable timestamp for all OpenStack resources accross releases
@@ -139,9 +196,22 @@ func main() {
 	flag.StringVar(&userName, "user", "ubuntu", "username used for sshing into the instance")
 	flag.BoolVar(&disableObjectStore, "disable-objectstore", false, "disable object store")
 	flag.BoolVar(&disableInstance, "disable-instance", false, "disable instance")
+	flag.StringVar(&configFile, "config-file", "", "path to a YAML file listing clouds to probe via /probe?target=<cloud_name>")
+	flag.StringVar(&pushGatewayURL, "push-gateway", "", "URL of a Prometheus Pushgateway; used instead of serving /metrics when -run-once is set")
+	flag.BoolVar(&runOnce, "run-once", false, "run the probe a single time and push the result to -push-gateway, instead of serving /metrics")
+	flag.IntVar(&maxConcurrentProbes, "max-concurrent-probes", 0, "maximum number of probes to run at once across all scrapes (0 means unlimited)")
+	flag.BoolVar(&exportTimestamp, "export-timestamp", false, "attach the probe start time as the sample timestamp, instead of letting Prometheus use scrape time (incompatible with -run-once/-push-gateway)")
+	flag.StringVar(&webListenAddress, "web.listen-address", "127.0.0.1:9539", "address to listen on for the web interface and telemetry")
+	flag.StringVar(&webConfigFile, "web.config-file", "", "path to a file enabling TLS and/or basic auth, in prometheus/exporter-toolkit web config format")
 
 	flag.Parse()
 
+	if runOnce && exportTimestamp {
+		log.Fatal("-export-timestamp cannot be used with -run-once/-push-gateway: the Pushgateway rejects samples that carry an explicit timestamp")
+	}
+
+	initProbeSemaphore()
+
 	// Check environment variables values
 	for _, e := range os.Environ() {
 		pair := strings.SplitN(e, "=", 2)
@@ -152,9 +222,22 @@ func main() {
 
 	go runGarbageCollector()
 
+	if runOnce {
+		runPushOnce()
+		return
+	}
+
 	// Handle prometheus metric requests
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/metrics", metricsHandler)
-	log.Fatal(http.ListenAndServe("127.0.0.1:9539", mux))
+	mux.HandleFunc("/probe", probeHandler)
+
+	server := &http.Server{Handler: mux}
+	flagConfig := &web.FlagConfig{
+		WebListenAddresses: &[]string{webListenAddress},
+		WebConfigFile:      &webConfigFile,
+	}
+
+	log.Fatal(web.ListenAndServe(server, flagConfig, kitlog.NewLogfmtLogger(kitlog.NewSyncWriter(os.Stderr))))
 }
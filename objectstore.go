@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/objectstorage/v1/containers"
+	"github.com/gophercloud/gophercloud/openstack/objectstorage/v1/objects"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const objectContent = "openstack-client-exporter liveness probe"
+
+// objectStoreMain uploads and downloads a file through the authenticated
+// Swift client, then exercises the unauthenticated TempURL path that
+// pre-signed download links depend on.
+func objectStoreMain(ctx context.Context, registry *prometheus.Registry) {
+	registry.MustRegister(tempURLDuration, tempURLSuccess)
+
+	sr := newStepRecorder(registry, "objectstore")
+	sr.start()
+	defer sr.finish()
+
+	provider, err := getProvider(ctx)
+	if err != nil {
+		log.Printf("objectStoreMain: %s", err)
+		sr.recordError("authenticate")
+		return
+	}
+
+	client, err := openstack.NewObjectStorageV1(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		log.Printf("objectStoreMain: cannot create object store client: %s", err)
+		sr.recordError("authenticate")
+		return
+	}
+	if err := sr.step(ctx, "authenticate"); err != nil {
+		return
+	}
+
+	containerName := createName()
+	objectName := "probe-object"
+
+	if _, err := containers.Create(client, containerName, containers.CreateOpts{}).Extract(); err != nil {
+		log.Printf("objectStoreMain: cannot create container: %s", err)
+		sr.recordError("create_container")
+		return
+	}
+	defer deleteContainer(client, containerName, objectName)
+	if err := sr.step(ctx, "create_container"); err != nil {
+		return
+	}
+
+	if _, err := objects.Create(client, containerName, objectName, objects.CreateOpts{
+		Content: bytes.NewReader([]byte(objectContent)),
+	}).Extract(); err != nil {
+		log.Printf("objectStoreMain: cannot upload object: %s", err)
+		sr.recordError("upload")
+		return
+	}
+	if err := sr.step(ctx, "upload"); err != nil {
+		return
+	}
+
+	downloadResult := objects.Download(client, containerName, objectName, objects.DownloadOpts{})
+	content, err := downloadResult.ExtractContent()
+	if err != nil {
+		log.Printf("objectStoreMain: cannot download object: %s", err)
+		sr.recordError("download")
+		return
+	}
+
+	if string(content) != objectContent {
+		log.Printf("objectStoreMain: downloaded content does not match what was uploaded")
+		sr.recordError("download")
+		return
+	}
+	if err := sr.step(ctx, "download"); err != nil {
+		return
+	}
+
+	if err := probeTempURL(ctx, client, containerName, objectName); err != nil {
+		log.Printf("objectStoreMain: temp url probe failed: %s", err)
+		sr.recordError("tempurl")
+		return
+	}
+	sr.step(ctx, "tempurl")
+}
+
+// deleteContainer removes objectName and its container, logging but
+// otherwise ignoring failures since the garbage collector will retry later.
+func deleteContainer(client *gophercloud.ServiceClient, containerName, objectName string) {
+	if _, err := objects.Delete(client, containerName, objectName, objects.DeleteOpts{}).Extract(); err != nil {
+		log.Printf("objectStoreMain: cannot delete object %s/%s: %s", containerName, objectName, err)
+	}
+
+	if _, err := containers.Delete(client, containerName).Extract(); err != nil {
+		log.Printf("objectStoreMain: cannot delete container %s: %s", containerName, err)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// runPushOnce runs the probe a single time and pushes the resulting registry
+// to -push-gateway instead of serving /metrics. This suits Kubernetes
+// CronJob / systemd-timer deployments where a long-running HTTP server plus
+// scrape schedule is overkill.
+func runPushOnce() {
+	registry := prometheus.NewRegistry()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	cc := envCloudConfig()
+	runProbe(ctx, registry, cc)
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	pusher := push.New(pushGatewayURL, program).
+		Gatherer(registry).
+		Grouping("instance", hostname).
+		Grouping("cloud", cc.Name)
+
+	if err := pusher.Push(); err != nil {
+		log.Fatalf("cannot push metrics to %s: %s", pushGatewayURL, err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	probeQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "openstack_client_probe_queue_depth",
+		Help: "Number of /metrics or /probe scrapes waiting for a probe slot",
+	})
+	probeInflight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "openstack_client_probe_inflight",
+		Help: "Number of /metrics or /probe scrapes currently running a probe",
+	})
+
+	// probeSlots is nil (unlimited concurrency) unless -max-concurrent-probes
+	// is set, in which case it holds maxConcurrentProbes tokens.
+	probeSlots chan struct{}
+)
+
+// initProbeSemaphore sizes the probe semaphore from -max-concurrent-probes.
+// It must be called once, after flag.Parse(), before the HTTP server starts.
+func initProbeSemaphore() {
+	if maxConcurrentProbes > 0 {
+		probeSlots = make(chan struct{}, maxConcurrentProbes)
+	}
+}
+
+// acquireProbeSlot blocks until a probe slot is available, counting callers
+// still waiting on probeQueueDepth. It is a no-op when -max-concurrent-probes
+// is unset. Callers must defer releaseProbeSlot().
+func acquireProbeSlot() {
+	if probeSlots == nil {
+		return
+	}
+
+	probeQueueDepth.Inc()
+	probeSlots <- struct{}{}
+	probeQueueDepth.Dec()
+
+	probeInflight.Inc()
+}
+
+// releaseProbeSlot frees the slot acquired by acquireProbeSlot.
+func releaseProbeSlot() {
+	if probeSlots == nil {
+		return
+	}
+
+	probeInflight.Dec()
+	<-probeSlots
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/acceptance/tools"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/images"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const sshPort = "22"
+
+// spawnMain provisions an instance on the probe's internal network, waits
+// for it to come up and become reachable over ssh, then tears it down again.
+// The flavor/image/network names it uses come from the CloudConfig attached
+// to ctx, so a /probe request for one cloud can never pick up another
+// cloud's overrides.
+func spawnMain(ctx context.Context, registry *prometheus.Registry) {
+	sr := newStepRecorder(registry, "spawn")
+	sr.start()
+	defer sr.finish()
+
+	cc := cloudConfigFromContext(ctx)
+
+	computeClient, networkClient, err := spawnClients(ctx)
+	if err != nil {
+		log.Printf("spawnMain: %s", err)
+		sr.recordError("authenticate")
+		return
+	}
+	if err := sr.step(ctx, "authenticate"); err != nil {
+		return
+	}
+
+	flavorID, err := flavorIDFromName(computeClient, flavorFor(cc))
+	if err != nil {
+		log.Printf("spawnMain: cannot resolve flavor: %s", err)
+		sr.recordError("resolve_flavor")
+		return
+	}
+
+	imageID, err := imageIDFromName(computeClient, imageFor(cc))
+	if err != nil {
+		log.Printf("spawnMain: cannot resolve image: %s", err)
+		sr.recordError("resolve_image")
+		return
+	}
+
+	networkID, err := networkIDFromName(networkClient, internalNetworkFor(cc))
+	if err != nil {
+		log.Printf("spawnMain: cannot resolve internal network: %s", err)
+		sr.recordError("resolve_network")
+		return
+	}
+	if err := sr.step(ctx, "resolve_references"); err != nil {
+		return
+	}
+
+	server, err := servers.Create(computeClient, servers.CreateOpts{
+		Name:      createName(),
+		FlavorRef: flavorID,
+		ImageRef:  imageID,
+		Networks:  []servers.Network{{UUID: networkID}},
+	}).Extract()
+	if err != nil {
+		log.Printf("spawnMain: cannot create server: %s", err)
+		sr.recordError("create_server")
+		return
+	}
+	if err := sr.step(ctx, "create_server"); err != nil {
+		return
+	}
+	defer func() {
+		if err := servers.Delete(computeClient, server.ID).ExtractErr(); err != nil {
+			log.Printf("spawnMain: cannot delete server %s: %s", server.ID, err)
+		}
+	}()
+
+	if err := tools.WaitFor(func() (bool, error) {
+		current, err := servers.Get(computeClient, server.ID).Extract()
+		if err != nil {
+			return false, err
+		}
+		return current.Status == "ACTIVE", nil
+	}); err != nil {
+		log.Printf("spawnMain: server never became active: %s", err)
+		sr.recordError("wait_active")
+		return
+	}
+	if err := sr.step(ctx, "wait_active"); err != nil {
+		return
+	}
+
+	server, err = servers.Get(computeClient, server.ID).Extract()
+	if err != nil {
+		log.Printf("spawnMain: cannot refresh server: %s", err)
+		sr.recordError("wait_active")
+		return
+	}
+
+	address, err := firstAddress(server)
+	if err != nil {
+		log.Printf("spawnMain: %s", err)
+		sr.recordError("ssh")
+		return
+	}
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(address, sshPort))
+	if err != nil {
+		log.Printf("spawnMain: cannot reach %s@%s over ssh: %s", userName, address, err)
+		sr.recordError("ssh")
+		return
+	}
+	conn.Close()
+	sr.step(ctx, "ssh")
+}
+
+// spawnClients authenticates and returns the compute and networking clients
+// spawnMain needs.
+func spawnClients(ctx context.Context) (*gophercloud.ServiceClient, *gophercloud.ServiceClient, error) {
+	provider, err := getProvider(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	computeClient, err := openstack.NewComputeV2(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create compute client: %s", err)
+	}
+
+	networkClient, err := openstack.NewNetworkV2(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create networking client: %s", err)
+	}
+
+	return computeClient, networkClient, nil
+}
+
+// flavorIDFromName resolves a flavor name to the ID servers.Create expects.
+// gophercloud has no built-in name lookup for compute flavors, so this pages
+// through ListDetail and matches on name itself.
+func flavorIDFromName(client *gophercloud.ServiceClient, name string) (string, error) {
+	pages, err := flavors.ListDetail(client, nil).AllPages()
+	if err != nil {
+		return "", fmt.Errorf("cannot list flavors: %s", err)
+	}
+
+	all, err := flavors.ExtractFlavors(pages)
+	if err != nil {
+		return "", fmt.Errorf("cannot extract flavors: %s", err)
+	}
+
+	for _, flavor := range all {
+		if flavor.Name == name {
+			return flavor.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("no flavor named %q", name)
+}
+
+// imageIDFromName resolves an image name to the ID servers.Create expects.
+func imageIDFromName(client *gophercloud.ServiceClient, name string) (string, error) {
+	pages, err := images.ListDetail(client, nil).AllPages()
+	if err != nil {
+		return "", fmt.Errorf("cannot list images: %s", err)
+	}
+
+	all, err := images.ExtractImages(pages)
+	if err != nil {
+		return "", fmt.Errorf("cannot extract images: %s", err)
+	}
+
+	for _, image := range all {
+		if image.Name == name {
+			return image.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("no image named %q", name)
+}
+
+// networkIDFromName resolves a network name to the ID servers.Create expects.
+func networkIDFromName(client *gophercloud.ServiceClient, name string) (string, error) {
+	pages, err := networks.List(client, nil).AllPages()
+	if err != nil {
+		return "", fmt.Errorf("cannot list networks: %s", err)
+	}
+
+	all, err := networks.ExtractNetworks(pages)
+	if err != nil {
+		return "", fmt.Errorf("cannot extract networks: %s", err)
+	}
+
+	for _, network := range all {
+		if network.Name == name {
+			return network.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("no network named %q", name)
+}
+
+// firstAddress returns the first IP address gophercloud reports for server,
+// regardless of which network it is attached to.
+func firstAddress(server *servers.Server) (string, error) {
+	for _, addresses := range server.Addresses {
+		list, ok := addresses.([]interface{})
+		if !ok || len(list) == 0 {
+			continue
+		}
+
+		entry, ok := list[0].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if addr, ok := entry["addr"].(string); ok {
+			return addr, nil
+		}
+	}
+
+	return "", fmt.Errorf("server %s has no addresses yet", server.ID)
+}
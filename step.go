@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stepBuckets covers individual probe steps, from sub-second API calls up to
+// a full instance boot.
+var stepBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 15, 30, 45, 60}
+
+// stepRecorder replaces the old SetToCurrentTime gauges with metrics that can
+// actually be turned into latency SLOs: a histogram of the elapsed time
+// between consecutive steps, a counter of step outcomes, and a gauge of how
+// many probes are currently in flight for a subsystem.
+type stepRecorder struct {
+	subsystem string
+
+	duration *prometheus.HistogramVec
+	outcomes *prometheus.CounterVec
+	inflight prometheus.Gauge
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// newStepRecorder creates a recorder for subsystem ("spawn", "objectstore",
+// ...) and registers its metrics on registry.
+func newStepRecorder(registry *prometheus.Registry, subsystem string) *stepRecorder {
+	sr := &stepRecorder{
+		subsystem: subsystem,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "openstack_client_step_duration_seconds",
+			Help:        "Elapsed time between consecutive probe steps",
+			Buckets:     stepBuckets,
+			ConstLabels: prometheus.Labels{"subsystem": subsystem},
+		}, []string{"step"}),
+		outcomes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "openstack_client_step_total",
+			Help:        "Count of probe steps by outcome (success, timeout, error)",
+			ConstLabels: prometheus.Labels{"subsystem": subsystem},
+		}, []string{"step", "outcome"}),
+		inflight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "openstack_client_subsystem_inflight",
+			Help:        "Number of probes currently in flight for this subsystem",
+			ConstLabels: prometheus.Labels{"subsystem": subsystem},
+		}),
+	}
+
+	registry.MustRegister(sr.duration, sr.outcomes, sr.inflight)
+
+	return sr
+}
+
+// start marks the beginning of a probe: it resets the elapsed-time clock and
+// increments the in-flight gauge. Callers must defer sr.finish().
+func (sr *stepRecorder) start() {
+	sr.mu.Lock()
+	sr.last = time.Now()
+	sr.mu.Unlock()
+
+	sr.inflight.Inc()
+}
+
+// finish marks the end of a probe, decrementing the in-flight gauge.
+func (sr *stepRecorder) finish() {
+	sr.inflight.Dec()
+}
+
+// step records the duration since the previous step (or since start) under
+// name, and reports a timeout outcome if ctx has already expired.
+func (sr *stepRecorder) step(ctx context.Context, name string) error {
+	sr.mu.Lock()
+	elapsed := time.Since(sr.last)
+	sr.last = time.Now()
+	sr.mu.Unlock()
+
+	sr.duration.With(prometheus.Labels{"step": name}).Observe(elapsed.Seconds())
+
+	select {
+	case <-ctx.Done():
+		sr.outcomes.With(prometheus.Labels{"step": name, "outcome": "timeout"}).Inc()
+		return fmt.Errorf("timeout after %s", name)
+	default:
+		sr.outcomes.With(prometheus.Labels{"step": name, "outcome": "success"}).Inc()
+		return nil
+	}
+}
+
+// recordError marks name as having failed outright (as opposed to timing
+// out), for callers that run a step and then get a hard error back from the
+// OpenStack API.
+func (sr *stepRecorder) recordError(name string) {
+	sr.outcomes.With(prometheus.Labels{"step": name, "outcome": "error"}).Inc()
+}
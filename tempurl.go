@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/acceptance/tools"
+	"github.com/gophercloud/gophercloud/openstack/objectstorage/v1/accounts"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	tempURLKeyMetadata = "Temp-Url-Key"
+	tempURLLifetime    = 5 * time.Minute
+)
+
+var (
+	tempURLDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "openstack_client_tempurl_duration_seconds",
+		Help: "Duration of the unauthenticated Swift TempURL GET",
+	})
+	tempURLSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "openstack_client_tempurl_success",
+		Help: "Whether the Swift TempURL GET succeeded (1) or not (0)",
+	})
+)
+
+// ensureTempURLKey returns the account's Temp-Url-Key, provisioning a random
+// one if the account does not already have one set. The key is required to
+// sign TempURLs and is otherwise only ever set by an operator once.
+func ensureTempURLKey(client *gophercloud.ServiceClient) (string, error) {
+	result := accounts.Get(client, accounts.GetOpts{})
+
+	metadata, err := result.Extract()
+	if err != nil {
+		return "", fmt.Errorf("cannot read account metadata: %s", err)
+	}
+
+	if key := metadata.TempURLKey; key != "" {
+		return key, nil
+	}
+
+	key := tools.RandomString("", 32)
+
+	_, err = accounts.Update(client, accounts.UpdateOpts{
+		TempURLKey: key,
+	}).Extract()
+	if err != nil {
+		return "", fmt.Errorf("cannot set temp url key: %s", err)
+	}
+
+	return key, nil
+}
+
+// tempURLSignature computes the HMAC-SHA1 signature Swift expects over
+// "GET\n<expires>\n<path>", as documented for the TempURL middleware.
+func tempURLSignature(key, path string, expires time.Time) string {
+	body := fmt.Sprintf("GET\n%d\n%s", expires.Unix(), path)
+
+	mac := hmac.New(sha1.New, []byte(key))
+	mac.Write([]byte(body))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// buildTempURL signs path with key and returns a fully qualified,
+// unauthenticated download URL valid until expires.
+func buildTempURL(baseURL, path, key string, expires time.Time) string {
+	sig := tempURLSignature(key, path, expires)
+
+	values := url.Values{}
+	values.Set("temp_url_sig", sig)
+	values.Set("temp_url_expires", fmt.Sprintf("%d", expires.Unix()))
+
+	return strings.TrimRight(baseURL, "/") + path + "?" + values.Encode()
+}
+
+// tempURLOriginAndPath splits a Swift endpoint such as
+// "https://swift.example.com/v1/AUTH_xxx" into the origin to request against
+// and the full request path to sign, including the account segment. Swift
+// validates the HMAC against that full path - signing just
+// "/container/object" is rejected with a 401 by a real TempURL middleware.
+func tempURLOriginAndPath(endpoint, containerName, objectName string) (origin, objectPath string, err error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot parse object store endpoint: %s", err)
+	}
+
+	return u.Scheme + "://" + u.Host, path.Join(u.Path, containerName, objectName), nil
+}
+
+// probeTempURL generates a TempURL for containerName/objectName and performs
+// an unauthenticated GET against it, recording the outcome on the tempurl
+// metrics. This exercises the public-facing Swift path that pre-signed
+// download links rely on, which the authenticated client alone cannot cover.
+func probeTempURL(ctx context.Context, client *gophercloud.ServiceClient, containerName, objectName string) error {
+	key, err := ensureTempURLKey(client)
+	if err != nil {
+		return err
+	}
+
+	origin, objectPath, err := tempURLOriginAndPath(client.Endpoint, containerName, objectName)
+	if err != nil {
+		return err
+	}
+
+	tempURL := buildTempURL(origin, objectPath, key, time.Now().Add(tempURLLifetime))
+
+	start := time.Now()
+
+	req, err := http.NewRequest(http.MethodGet, tempURL, nil)
+	if err != nil {
+		tempURLSuccess.Set(0)
+		return fmt.Errorf("cannot build temp url request: %s", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	tempURLDuration.Set(time.Since(start).Seconds())
+
+	if err != nil {
+		tempURLSuccess.Set(0)
+		return fmt.Errorf("temp url request failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	_, err = ioutil.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		tempURLSuccess.Set(0)
+		return fmt.Errorf("temp url request returned status %d", resp.StatusCode)
+	}
+
+	tempURLSuccess.Set(1)
+	return nil
+}
+
+// cleanupTempURLKey removes the Temp-Url-Key from the account. It does not
+// tell a key provisioned by ensureTempURLKey from one an operator set: any
+// key present is removed. It is called by runGarbageCollector alongside the
+// other resource cleanup it performs.
+func cleanupTempURLKey(client *gophercloud.ServiceClient) error {
+	_, err := accounts.Update(client, accounts.UpdateOpts{
+		RemoveMetadata: []string{tempURLKeyMetadata},
+	}).Extract()
+
+	return err
+}
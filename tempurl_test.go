@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Fixture taken from the OpenStack Swift TempURL documentation example:
+// key "mykey", path "/v1/AUTH_test/container/object", expires 1323479485.
+func TestTempURLSignature(t *testing.T) {
+	expires := time.Unix(1323479485, 0)
+
+	got := tempURLSignature("mykey", "/v1/AUTH_test/container/object", expires)
+	want := "8bac9a20338861d3c9db04bcad0de42aa9a2860c"
+
+	if got != want {
+		t.Errorf("tempURLSignature() = %q, want %q", got, want)
+	}
+}
+
+func TestTempURLOriginAndPath(t *testing.T) {
+	origin, objectPath, err := tempURLOriginAndPath("https://swift.example.com/v1/AUTH_test", "mycontainer", "myobject")
+	if err != nil {
+		t.Fatalf("tempURLOriginAndPath() error = %v", err)
+	}
+
+	if want := "https://swift.example.com"; origin != want {
+		t.Errorf("origin = %q, want %q", origin, want)
+	}
+
+	if want := "/v1/AUTH_test/mycontainer/myobject"; objectPath != want {
+		t.Errorf("objectPath = %q, want %q", objectPath, want)
+	}
+}
+
+func TestBuildTempURL(t *testing.T) {
+	expires := time.Unix(1323479485, 0)
+
+	got := buildTempURL("https://swift.example.com", "/v1/AUTH_test/container/object", "mykey", expires)
+	want := "https://swift.example.com/v1/AUTH_test/container/object?temp_url_expires=1323479485&temp_url_sig=8bac9a20338861d3c9db04bcad0de42aa9a2860c"
+
+	if got != want {
+		t.Errorf("buildTempURL() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gathererFor returns registry as-is, unless -export-timestamp is set, in
+// which case it is wrapped so every sample is stamped with start instead of
+// scrape time.
+func gathererFor(registry *prometheus.Registry, start time.Time) prometheus.Gatherer {
+	if !exportTimestamp {
+		return registry
+	}
+
+	return timestampGatherer{
+		Gatherer:    registry,
+		timestampMs: start.UnixNano() / int64(time.Millisecond),
+	}
+}
+
+// timestampGatherer wraps a Gatherer and stamps every sample it returns with
+// a fixed timestamp, rather than letting Prometheus default to scrape time.
+// This matters for probes that take tens of seconds: without it, a slow
+// instance boot gets attributed to whenever Prometheus happened to scrape,
+// not to when the probe actually ran.
+type timestampGatherer struct {
+	prometheus.Gatherer
+	timestampMs int64
+}
+
+func (g timestampGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	if err != nil {
+		return families, err
+	}
+
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			metric.TimestampMs = &g.timestampMs
+		}
+	}
+
+	return families, nil
+}
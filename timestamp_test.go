@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestTimestampGathererStampsEverySample(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge"})
+	gauge.Set(1)
+	registry.MustRegister(gauge)
+
+	const wantMs = int64(1700000000000)
+	g := timestampGatherer{Gatherer: registry, timestampMs: wantMs}
+
+	families, err := g.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	seen := 0
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			seen++
+			if metric.TimestampMs == nil || *metric.TimestampMs != wantMs {
+				t.Errorf("metric %s TimestampMs = %v, want %d", family.GetName(), metric.TimestampMs, wantMs)
+			}
+		}
+	}
+
+	if seen == 0 {
+		t.Fatal("Gather() returned no metrics to check")
+	}
+}
+
+func TestGathererForOnlyWrapsWhenExportTimestampSet(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	exportTimestamp = false
+	if g := gathererFor(registry, time.Now()); g != prometheus.Gatherer(registry) {
+		t.Errorf("gathererFor() = %v, want registry unwrapped when -export-timestamp is not set", g)
+	}
+
+	exportTimestamp = true
+	defer func() { exportTimestamp = false }()
+
+	if _, ok := gathererFor(registry, time.Now()).(timestampGatherer); !ok {
+		t.Error("gathererFor() did not wrap registry when -export-timestamp is set")
+	}
+}